@@ -5,10 +5,9 @@
 package modfile
 
 import (
-	"bytes"
-	"errors"
 	"fmt"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -21,9 +20,11 @@ import (
 // A File is the parsed, interpreted form of a go.mod file.
 type File struct {
 	Module  *Module
+	Go      *Go
 	Require []*Require
 	Exclude []*Exclude
 	Replace []*Replace
+	Retract []*Retract
 
 	Syntax *FileSyntax
 }
@@ -34,6 +35,12 @@ type Module struct {
 	Syntax *Line
 }
 
+// A Go is the go statement.
+type Go struct {
+	Version string // "1.23"
+	Syntax  *Line
+}
+
 // A Require is a single require statement.
 type Require struct {
 	Mod    module.Version
@@ -53,6 +60,20 @@ type Replace struct {
 	Syntax *Line
 }
 
+// A Retract is a single retract statement.
+type Retract struct {
+	VersionInterval
+	Rationale string
+	Syntax    *Line
+}
+
+// A VersionInterval represents a range of versions with upper and lower
+// bounds. Each retraction is represented by a VersionInterval: a single
+// retracted version is one where Low == High.
+type VersionInterval struct {
+	Low, High string
+}
+
 func (f *File) AddModuleStmt(path string) error {
 	if f.Syntax == nil {
 		f.Syntax = new(FileSyntax)
@@ -69,6 +90,40 @@ func (f *File) AddModuleStmt(path string) error {
 	return nil
 }
 
+// AddGoStmt adds a go statement updating the go version. It fails if
+// the given string is not a valid go version.
+func (f *File) AddGoStmt(version string) error {
+	if !goVersionRE.MatchString(version) {
+		return fmt.Errorf("invalid language version string %q", version)
+	}
+	if f.Syntax == nil {
+		f.Syntax = new(FileSyntax)
+	}
+	if f.Go == nil {
+		var hint *Line
+		if f.Module != nil {
+			hint = f.Module.Syntax
+		}
+		f.Go = &Go{
+			Version: version,
+			Syntax:  f.Syntax.addLine(hint, "go", version),
+		}
+	} else {
+		f.Go.Version = version
+		f.Syntax.updateLine(f.Go.Syntax, "go", version)
+	}
+	return nil
+}
+
+// DropGoStmt deletes the go statement from the file.
+func (f *File) DropGoStmt() error {
+	if f.Go != nil {
+		f.Syntax.removeLine(f.Go.Syntax)
+		f.Go = nil
+	}
+	return nil
+}
+
 func (f *File) AddComment(text string) {
 	if f.Syntax == nil {
 		f.Syntax = new(FileSyntax)
@@ -86,7 +141,94 @@ func (f *File) AddComment(text string) {
 
 type VersionFixer func(path, version string) (string, error)
 
+// goVersionRE is a restricted subset of the semver syntax: it requires
+// a major.minor version with an optional dotted patch and nothing else.
+// It intentionally does not allow a leading "v", "-pre" or "+meta"
+// suffixes, since the go directive only ever specifies a language version.
+var goVersionRE = regexp.MustCompile(`^([1-9][0-9]*)\.(0|[1-9][0-9]*)(\.(0|[1-9][0-9]*))?$`)
+
+// An Error describes a problem parsing one line of a go.mod file, giving
+// the caller structured access to the offending file, position, directive,
+// and module path instead of a formatted string.
+type Error struct {
+	Filename string
+	Pos      Position
+	Verb     string
+	ModPath  string
+	Err      error
+}
+
+func (e *Error) Error() string {
+	var pos string
+	if e.Pos.Line > 0 {
+		pos = fmt.Sprintf("%s:%d: ", e.Filename, e.Pos.Line)
+	} else if e.Filename != "" {
+		pos = fmt.Sprintf("%s: ", e.Filename)
+	}
+	return pos + e.Err.Error()
+}
+
+// Unwrap returns the underlying cause, so callers can use errors.As to
+// recover e.g. a module.InvalidMajorVersionError.
+func (e *Error) Unwrap() error { return e.Err }
+
+// An ErrorList is a list of Error values encountered while parsing a go.mod file.
+// It implements error so it can still be returned directly from Parse.
+type ErrorList []Error
+
+func (e ErrorList) Error() string {
+	var b strings.Builder
+	for i := range e {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(e[i].Error())
+	}
+	return b.String()
+}
+
+// errorf appends a structured parse error for line to errs.
+func (f *File) errorf(errs *ErrorList, line *Line, verb, modPath, format string, args ...interface{}) {
+	*errs = append(*errs, Error{
+		Filename: f.Syntax.Name,
+		Pos:      line.Start,
+		Verb:     verb,
+		ModPath:  modPath,
+		Err:      fmt.Errorf(format, args...),
+	})
+}
+
+// wrapModPathError wraps an error encountered while validating modPath so
+// that callers can still errors.As the original cause.
+func wrapModPathError(modPath string, err error) error {
+	return fmt.Errorf("%s: %w", modPath, err)
+}
+
+// Parse parses and returns a go.mod file.
+//
+// file is the name of the file, used in positions and errors.
+//
+// data is the content of the file.
+//
+// fix is an optional function that canonicalizes module versions.
+// If fix is nil, all module versions must be valid semantic versions; each is
+// rewritten to its canonical form (module.CanonicalVersion).
+//
+// Unknown directives and unknown block types are treated as hard errors.
+// Use ParseLax to tolerate go.mod files written by newer toolchains.
 func Parse(file string, data []byte, fix VersionFixer) (*File, error) {
+	return parseToFile(file, data, fix, true)
+}
+
+// ParseLax is like Parse but ignores unknown statements.
+// It is used when parsing go.mod files other than the main module,
+// under the theory that most statement types we omit from the parser
+// are not going to affect the worthwhile results.
+func ParseLax(file string, data []byte, fix VersionFixer) (*File, error) {
+	return parseToFile(file, data, fix, false)
+}
+
+func parseToFile(file string, data []byte, fix VersionFixer, strict bool) (*File, error) {
 	fs, err := parse(file, data)
 	if err != nil {
 		return nil, err
@@ -95,41 +237,48 @@ func Parse(file string, data []byte, fix VersionFixer) (*File, error) {
 		Syntax: fs,
 	}
 
-	var errs bytes.Buffer
+	var errs ErrorList
 	for _, x := range fs.Stmt {
 		switch x := x.(type) {
 		case *Line:
-			f.add(&errs, x, x.Token[0], x.Token[1:], fix)
+			f.add(&errs, x, x.Token[0], x.Token[1:], fix, strict)
 
 		case *LineBlock:
 			if len(x.Token) > 1 {
-				fmt.Fprintf(&errs, "%s:%d: unknown block type: %s\n", file, x.Start.Line, strings.Join(x.Token, " "))
+				if strict {
+					errs = append(errs, Error{
+						Filename: file,
+						Pos:      x.Start,
+						Err:      fmt.Errorf("unknown block type: %s", strings.Join(x.Token, " ")),
+					})
+				}
 				continue
 			}
 			switch x.Token[0] {
 			default:
-				fmt.Fprintf(&errs, "%s:%d: unknown block type: %s\n", file, x.Start.Line, strings.Join(x.Token, " "))
+				if strict {
+					errs = append(errs, Error{
+						Filename: file,
+						Pos:      x.Start,
+						Err:      fmt.Errorf("unknown block type: %s", strings.Join(x.Token, " ")),
+					})
+				}
 				continue
-			case "module", "require", "exclude", "replace":
+			case "module", "require", "exclude", "replace", "retract":
 				for _, l := range x.Line {
-					f.add(&errs, l, x.Token[0], l.Token, fix)
+					f.add(&errs, l, x.Token[0], l.Token, fix, strict)
 				}
 			}
 		}
 	}
 
-	if errs.Len() > 0 {
-		return nil, errors.New(strings.TrimRight(errs.String(), "\n"))
+	if len(errs) > 0 {
+		return nil, errs
 	}
 	return f, nil
 }
 
-func (f *File) add(errs *bytes.Buffer, line *Line, verb string, args []string, fix VersionFixer) {
-	// TODO: We should pass in a flag saying whether this module is a dependency.
-	// If so, we should ignore all unknown directives and not attempt to parse
-	// replace and exclude either. They don't matter, and it will work better for
-	// forward compatibility if we can depend on modules that have local changes.
-
+func (f *File) add(errs *ErrorList, line *Line, verb string, args []string, fix VersionFixer, strict bool) {
 	// TODO: For the target module (not dependencies), maybe we should
 	// relax the semver requirement and rewrite the file with updated info
 	// after resolving any versions. That would let people type commit hashes
@@ -137,47 +286,59 @@ func (f *File) add(errs *bytes.Buffer, line *Line, verb string, args []string, f
 
 	switch verb {
 	default:
-		fmt.Fprintf(errs, "%s:%d: unknown directive: %s\n", f.Syntax.Name, line.Start.Line, verb)
+		if strict {
+			f.errorf(errs, line, verb, "", "unknown directive: %s", verb)
+		}
 	case "module":
 		if f.Module != nil {
-			fmt.Fprintf(errs, "%s:%d: repeated module statement\n", f.Syntax.Name, line.Start.Line)
+			f.errorf(errs, line, verb, "", "repeated module statement")
 			return
 		}
 		f.Module = &Module{Syntax: line}
 		if len(args) != 1 {
-
-			fmt.Fprintf(errs, "%s:%d: usage: module module/path [version]\n", f.Syntax.Name, line.Start.Line)
+			f.errorf(errs, line, verb, "", "usage: module module/path [version]")
 			return
 		}
 		s, err := parseString(&args[0])
 		if err != nil {
-			fmt.Fprintf(errs, "%s:%d: invalid quoted string: %v\n", f.Syntax.Name, line.Start.Line, err)
+			f.errorf(errs, line, verb, "", "invalid quoted string: %v", err)
 			return
 		}
 		f.Module.Mod = module.Version{Path: s}
+	case "go":
+		if f.Go != nil {
+			f.errorf(errs, line, verb, "", "repeated go statement")
+			return
+		}
+		f.Go = &Go{Syntax: line}
+		if len(args) != 1 || !goVersionRE.MatchString(args[0]) {
+			f.errorf(errs, line, verb, "", "usage: go 1.23")
+			return
+		}
+		f.Go.Version = args[0]
 	case "require", "exclude":
 		if len(args) != 2 {
-			fmt.Fprintf(errs, "%s:%d: usage: %s module/path v1.2.3\n", f.Syntax.Name, line.Start.Line, verb)
+			f.errorf(errs, line, verb, "", "usage: %s module/path v1.2.3", verb)
 			return
 		}
 		s, err := parseString(&args[0])
 		if err != nil {
-			fmt.Fprintf(errs, "%s:%d: invalid quoted string: %v\n", f.Syntax.Name, line.Start.Line, err)
+			f.errorf(errs, line, verb, "", "invalid quoted string: %v", err)
 			return
 		}
 		old := args[1]
 		v, err := parseVersion(s, &args[1], fix)
 		if err != nil {
-			fmt.Fprintf(errs, "%s:%d: invalid module version %q: %v\n", f.Syntax.Name, line.Start.Line, old, err)
+			f.errorf(errs, line, verb, s, "invalid module version %q: %v", old, err)
 			return
 		}
-		v1, err := moduleMajorVersion(s)
+		pathMajor, err := modulePathMajor(s)
 		if err != nil {
-			fmt.Fprintf(errs, "%s:%d: %v\n", f.Syntax.Name, line.Start.Line, err)
+			f.errorf(errs, line, verb, s, "%w", wrapModPathError(s, err))
 			return
 		}
-		if v2 := semver.Major(v); v1 != v2 && (v1 != "v1" || v2 != "v0") {
-			fmt.Fprintf(errs, "%s:%d: invalid module: %s should be %s, not %s (%s)\n", f.Syntax.Name, line.Start.Line, s, v1, v2, v)
+		if err := module.CheckPathMajor(v, pathMajor); err != nil {
+			f.errorf(errs, line, verb, s, "%w", wrapModPathError(s, err))
 			return
 		}
 		if verb == "require" {
@@ -192,55 +353,64 @@ func (f *File) add(errs *bytes.Buffer, line *Line, verb string, args []string, f
 			})
 		}
 	case "replace":
-		if len(args) < 4 || len(args) > 5 || args[2] != "=>" {
-			fmt.Fprintf(errs, "%s:%d: usage: %s module/path v1.2.3 => other/module v1.4\n\t or %s module/path v1.2.3 => ../local/directory", f.Syntax.Name, line.Start.Line, verb, verb)
+		// The old version is optional: "replace module/path => other/module v1.4"
+		// replaces every version of module/path, not just one pinned version.
+		arrow := 2
+		if len(args) >= 2 && args[1] == "=>" {
+			arrow = 1
+		}
+		if len(args) < arrow+2 || len(args) > arrow+3 || args[arrow] != "=>" {
+			f.errorf(errs, line, verb, "", "usage: %s module/path [v1.2.3] => other/module v1.4\n\t or %s module/path [v1.2.3] => ../local/directory", verb, verb)
 			return
 		}
 		s, err := parseString(&args[0])
 		if err != nil {
-			fmt.Fprintf(errs, "%s:%d: invalid quoted string: %v\n", f.Syntax.Name, line.Start.Line, err)
+			f.errorf(errs, line, verb, "", "invalid quoted string: %v", err)
 			return
 		}
-		old := args[1]
-		v, err := parseVersion(s, &args[1], fix)
-		if err != nil {
-			fmt.Fprintf(errs, "%s:%d: invalid module version %v: %v\n", f.Syntax.Name, line.Start.Line, old, err)
-			return
-		}
-		v1, err := moduleMajorVersion(s)
-		if err != nil {
-			fmt.Fprintf(errs, "%s:%d: %v\n", f.Syntax.Name, line.Start.Line, err)
-			return
-		}
-		if v2 := semver.Major(v); v1 != v2 && (v1 != "v1" || v2 != "v0") {
-			fmt.Fprintf(errs, "%s:%d: invalid module: %s should be %s, not %s (%s)\n", f.Syntax.Name, line.Start.Line, s, v1, v2, v)
-			return
+		v := ""
+		if arrow == 2 {
+			old := args[1]
+			v, err = parseVersion(s, &args[1], fix)
+			if err != nil {
+				f.errorf(errs, line, verb, s, "invalid module version %v: %v", old, err)
+				return
+			}
+			pathMajor, err := modulePathMajor(s)
+			if err != nil {
+				f.errorf(errs, line, verb, s, "%w", wrapModPathError(s, err))
+				return
+			}
+			if err := module.CheckPathMajor(v, pathMajor); err != nil {
+				f.errorf(errs, line, verb, s, "%w", wrapModPathError(s, err))
+				return
+			}
 		}
-		ns, err := parseString(&args[3])
+		ns, err := parseString(&args[arrow+1])
 		if err != nil {
-			fmt.Fprintf(errs, "%s:%d: invalid quoted string: %v\n", f.Syntax.Name, line.Start.Line, err)
+			f.errorf(errs, line, verb, "", "invalid quoted string: %v", err)
 			return
 		}
 		nv := ""
-		if len(args) == 4 {
+		if len(args) == arrow+2 {
 			if !IsDirectoryPath(ns) {
-				fmt.Fprintf(errs, "%s:%d: replacement module without version must be directory path (rooted or starting with ./ or ../)", f.Syntax.Name, line.Start.Line)
+				f.errorf(errs, line, verb, ns, "replacement module without version must be directory path (rooted or starting with ./ or ../)")
 				return
 			}
 			if filepath.Separator == '/' && strings.Contains(ns, `\`) {
-				fmt.Fprintf(errs, "%s:%d: replacement directory appears to be Windows path (on a non-windows system)", f.Syntax.Name, line.Start.Line)
+				f.errorf(errs, line, verb, ns, "replacement directory appears to be Windows path (on a non-windows system)")
 				return
 			}
 		}
-		if len(args) == 5 {
-			old := args[4]
-			nv, err = parseVersion(ns, &args[4], fix)
+		if len(args) == arrow+3 {
+			old := args[arrow+2]
+			nv, err = parseVersion(ns, &args[arrow+2], fix)
 			if err != nil {
-				fmt.Fprintf(errs, "%s:%d: invalid module version %v: %v\n", f.Syntax.Name, line.Start.Line, old, err)
+				f.errorf(errs, line, verb, ns, "invalid module version %v: %v", old, err)
 				return
 			}
 			if IsDirectoryPath(ns) {
-				fmt.Fprintf(errs, "%s:%d: replacement module directory path %q cannot have version", f.Syntax.Name, line.Start.Line, ns)
+				f.errorf(errs, line, verb, ns, "replacement module directory path %q cannot have version", ns)
 				return
 			}
 		}
@@ -250,9 +420,79 @@ func (f *File) add(errs *bytes.Buffer, line *Line, verb string, args []string, f
 			New:    module.Version{Path: ns, Version: nv},
 			Syntax: line,
 		})
+	case "retract":
+		modPath := ""
+		if f.Module != nil {
+			modPath = f.Module.Mod.Path
+		}
+		vi, err := parseVersionInterval(modPath, args, fix)
+		if err != nil {
+			f.errorf(errs, line, verb, "", "invalid retract statement: %v", err)
+			return
+		}
+		f.Retract = append(f.Retract, &Retract{
+			VersionInterval: vi,
+			Rationale:       retractRationale(line),
+			Syntax:          line,
+		})
 	}
 }
 
+// parseVersionInterval parses the tokens following a retract directive,
+// which are either a single version (retract v1.2.3) or a bracketed,
+// comma-separated range (retract [v1.0.0, v1.2.0]). modPath is the file's
+// own module path, since a retraction always applies to a version of the
+// module declaring it; it is passed through to fix like any other version
+// reference so path-aware VersionFixers see the real path, not "".
+func parseVersionInterval(modPath string, args []string, fix VersionFixer) (VersionInterval, error) {
+	if len(args) == 0 {
+		return VersionInterval{}, fmt.Errorf("usage: retract v1.2.3 or retract [v1.0.0, v1.2.0]")
+	}
+	joined := strings.Join(args, " ")
+	if !strings.HasPrefix(joined, "[") {
+		if len(args) != 1 {
+			return VersionInterval{}, fmt.Errorf("usage: retract v1.2.3")
+		}
+		v, err := parseVersion(modPath, &args[0], fix)
+		if err != nil {
+			return VersionInterval{}, err
+		}
+		return VersionInterval{Low: v, High: v}, nil
+	}
+	if !strings.HasSuffix(joined, "]") {
+		return VersionInterval{}, fmt.Errorf("usage: retract [v1.0.0, v1.2.0]")
+	}
+	joined = strings.TrimSuffix(strings.TrimPrefix(joined, "["), "]")
+	parts := strings.SplitN(joined, ",", 2)
+	if len(parts) != 2 {
+		return VersionInterval{}, fmt.Errorf("usage: retract [v1.0.0, v1.2.0]")
+	}
+	low := strings.TrimSpace(parts[0])
+	high := strings.TrimSpace(parts[1])
+	lv, err := parseVersion(modPath, &low, fix)
+	if err != nil {
+		return VersionInterval{}, err
+	}
+	hv, err := parseVersion(modPath, &high, fix)
+	if err != nil {
+		return VersionInterval{}, err
+	}
+	if semver.Compare(lv, hv) > 0 {
+		return VersionInterval{}, fmt.Errorf("retract lower bound %s must not be greater than upper bound %s", lv, hv)
+	}
+	return VersionInterval{Low: lv, High: hv}, nil
+}
+
+// retractRationale extracts the rationale for a retraction from the
+// comment trailing the retract line, if any.
+func retractRationale(line *Line) string {
+	var lines []string
+	for _, c := range line.Comments.Suffix {
+		lines = append(lines, strings.TrimSpace(strings.TrimPrefix(c.Token, "//")))
+	}
+	return strings.Join(lines, " ")
+}
+
 // IsDirectoryPath reports whether the given path should be interpreted
 // as a directory path. Just like on the go command line, relative paths
 // and rooted paths are directory paths; the rest are module paths.
@@ -314,13 +554,18 @@ func parseVersion(path string, s *string, fix VersionFixer) (string, error) {
 		}
 	}
 	if semver.IsValid(t) {
-		*s = semver.Canonical(t)
+		*s = module.CanonicalVersion(t)
 		return *s, nil
 	}
 	return "", fmt.Errorf("version must be of the form v1.2.3")
 }
 
-func moduleMajorVersion(p string) (string, error) {
+// modulePathMajor returns the major version suffix implied by a module
+// path, resolving gopkg.in paths to their "vN" form via ParseGopkgIn.
+// Paths without an explicit major version suffix report "v1", matching
+// module.CheckPathMajor's convention that v0 and v1 versions are both
+// accepted under an unsuffixed or "v1"-suffixed path.
+func modulePathMajor(p string) (string, error) {
 	if _, _, major, _, ok := ParseGopkgIn(p); ok {
 		return major, nil
 	}
@@ -384,6 +629,15 @@ func (f *File) Cleanup() {
 	}
 	f.Replace = f.Replace[:w]
 
+	w = 0
+	for _, r := range f.Retract {
+		if r.Low != "" || r.High != "" {
+			f.Retract[w] = r
+			w++
+		}
+	}
+	f.Retract = f.Retract[:w]
+
 	f.Syntax.Cleanup()
 }
 
@@ -498,11 +752,18 @@ func (f *File) DropExclude(path, vers string) error {
 	return nil
 }
 
+// AddReplace adds a replace directive. An empty oldVers applies the
+// replacement to every version of oldPath instead of a single pinned one.
 func (f *File) AddReplace(oldPath, oldVers, newPath, newVers string) error {
 	need := true
 	old := module.Version{Path: oldPath, Version: oldVers}
 	new := module.Version{Path: newPath, Version: newVers}
-	tokens := []string{"replace", AutoQuote(oldPath), oldVers, "=>", AutoQuote(newPath)}
+	var tokens []string
+	if oldVers == "" {
+		tokens = []string{"replace", AutoQuote(oldPath), "=>", AutoQuote(newPath)}
+	} else {
+		tokens = []string{"replace", AutoQuote(oldPath), oldVers, "=>", AutoQuote(newPath)}
+	}
 	if newVers != "" {
 		tokens = append(tokens, newVers)
 	}
@@ -541,6 +802,53 @@ func (f *File) DropReplace(oldPath, oldVers string) error {
 	return nil
 }
 
+// AddRetract adds a retract statement for the given version interval,
+// with an optional rationale recorded as a trailing comment. It returns
+// an error if either endpoint is not a valid semantic version or if
+// vi.Low comes after vi.High.
+func (f *File) AddRetract(vi VersionInterval, rationale string) error {
+	if !semver.IsValid(vi.Low) {
+		return fmt.Errorf("invalid retract version %q", vi.Low)
+	}
+	if !semver.IsValid(vi.High) {
+		return fmt.Errorf("invalid retract version %q", vi.High)
+	}
+	vi.Low = module.CanonicalVersion(vi.Low)
+	vi.High = module.CanonicalVersion(vi.High)
+	if semver.Compare(vi.Low, vi.High) > 0 {
+		return fmt.Errorf("retract lower bound %s must not be greater than upper bound %s", vi.Low, vi.High)
+	}
+
+	var r *Retract
+	if vi.Low == vi.High {
+		r = &Retract{
+			VersionInterval: vi,
+			Syntax:          f.Syntax.addLine(nil, "retract", AutoQuote(vi.Low)),
+		}
+	} else {
+		r = &Retract{
+			VersionInterval: vi,
+			Syntax:          f.Syntax.addLine(nil, "retract", "[", AutoQuote(vi.Low)+",", AutoQuote(vi.High), "]"),
+		}
+	}
+	if rationale != "" {
+		r.Rationale = rationale
+		r.Syntax.Comments.Suffix = append(r.Syntax.Comments.Suffix, Comment{Token: "// " + rationale})
+	}
+	f.Retract = append(f.Retract, r)
+	return nil
+}
+
+func (f *File) DropRetract(vi VersionInterval) error {
+	for _, r := range f.Retract {
+		if r.VersionInterval == vi {
+			f.Syntax.removeLine(r.Syntax)
+			*r = Retract{}
+		}
+	}
+	return nil
+}
+
 func (f *File) SortBlocks() {
 	f.removeDups() // otherwise sorting is unsafe
 
@@ -581,7 +889,10 @@ func (f *File) removeDups() {
 	f.Exclude = excl
 
 	have = make(map[module.Version]bool)
-	// Later replacements take priority over earlier ones.
+	// Later replacements take priority over earlier ones. A replace without a
+	// version (Old.Version == "") is keyed separately from any version-specific
+	// replace for the same path, so a wildcard replace never shadows or is
+	// shadowed by one pinned to a particular version.
 	for i := len(f.Replace) - 1; i >= 0; i-- {
 		x := f.Replace[i]
 		if have[x.Old] {
@@ -598,6 +909,22 @@ func (f *File) removeDups() {
 	}
 	f.Replace = repl
 
+	haveRetract := make(map[VersionInterval]bool)
+	for _, x := range f.Retract {
+		if haveRetract[x.VersionInterval] {
+			kill[x.Syntax] = true
+			continue
+		}
+		haveRetract[x.VersionInterval] = true
+	}
+	var retract []*Retract
+	for _, x := range f.Retract {
+		if !kill[x.Syntax] {
+			retract = append(retract, x)
+		}
+	}
+	f.Retract = retract
+
 	var stmts []Expr
 	for _, stmt := range f.Syntax.Stmt {
 		switch stmt := stmt.(type) {
@@ -0,0 +1,240 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package modfile
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddGoStmt(t *testing.T) {
+	tests := []struct {
+		version string
+		wantErr bool
+	}{
+		{"1.14", false},
+		{"1.14.2", false},
+		{"1", true},
+		{"1.", true},
+		{"go1.14", true},
+		{"v1.14", true},
+	}
+	for _, tt := range tests {
+		f := &File{}
+		err := f.AddGoStmt(tt.version)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("AddGoStmt(%q): want error, got nil", tt.version)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("AddGoStmt(%q): unexpected error: %v", tt.version, err)
+			continue
+		}
+		if f.Go == nil || f.Go.Version != tt.version {
+			t.Errorf("AddGoStmt(%q): f.Go = %+v, want Version %q", tt.version, f.Go, tt.version)
+		}
+	}
+}
+
+func TestAddRetractRejectsBackwardsInterval(t *testing.T) {
+	f := &File{}
+	err := f.AddRetract(VersionInterval{Low: "v1.2.0", High: "v1.0.0"}, "")
+	if err == nil {
+		t.Fatal("AddRetract with Low > High: want error, got nil")
+	}
+	if len(f.Retract) != 0 {
+		t.Fatalf("AddRetract with Low > High: f.Retract = %v, want empty", f.Retract)
+	}
+}
+
+func TestAddRetractRejectsInvalidVersion(t *testing.T) {
+	f := &File{}
+	if err := f.AddRetract(VersionInterval{Low: "not-a-version", High: "v1.0.0"}, ""); err == nil {
+		t.Fatal("AddRetract with invalid version: want error, got nil")
+	}
+}
+
+func TestParseVersionIntervalRejectsBackwardsRange(t *testing.T) {
+	_, err := parseVersionInterval("example.com/m", []string{"[", "v1.2.0,", "v1.0.0", "]"}, nil)
+	if err == nil {
+		t.Fatal("parseVersionInterval([v1.2.0, v1.0.0]): want error, got nil")
+	}
+}
+
+func TestParseRequireIncompatible(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"unsuffixed path", "module m\n\nrequire example.com/foo v2.0.0+incompatible\n"},
+		{"v2 suffixed path", "module m\n\nrequire example.com/foo/v2 v2.0.0+incompatible\n"},
+	}
+	for _, tt := range tests {
+		f, err := Parse(tt.name, []byte(tt.data), nil)
+		if err != nil {
+			t.Fatalf("%s: Parse: %v", tt.name, err)
+		}
+		if len(f.Require) != 1 {
+			t.Fatalf("%s: len(f.Require) = %d, want 1", tt.name, len(f.Require))
+		}
+		if !strings.HasSuffix(f.Require[0].Mod.Version, "+incompatible") {
+			t.Errorf("%s: require version = %q, want +incompatible suffix preserved", tt.name, f.Require[0].Mod.Version)
+		}
+	}
+}
+
+func TestParseLaxPreservesUnknownDirectives(t *testing.T) {
+	const data = `module m
+
+go 1.14
+
+toolchain go1.22.0
+
+require example.com/foo v1.0.0
+
+godebug (
+	foo bar
+)
+`
+
+	if _, err := Parse("go.mod", []byte(data), nil); err == nil {
+		t.Fatal("Parse: want error for unknown directive and block type, got nil")
+	}
+
+	f, err := ParseLax("go.mod", []byte(data), nil)
+	if err != nil {
+		t.Fatalf("ParseLax: unexpected error: %v", err)
+	}
+	if f.Go == nil || f.Go.Version != "1.14" {
+		t.Fatalf("ParseLax: f.Go = %+v, want Version 1.14", f.Go)
+	}
+	if len(f.Require) != 1 {
+		t.Fatalf("ParseLax: len(f.Require) = %d, want 1", len(f.Require))
+	}
+
+	out, err := f.Format()
+	if err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+	for _, want := range []string{"toolchain go1.22.0", "godebug (", "foo bar"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("Format output missing unknown statement %q:\n%s", want, out)
+		}
+	}
+
+	// The unknown statements must also survive a second round trip
+	// unchanged, confirming ParseLax/Format preserve them rather than
+	// happening to pass through once.
+	f2, err := ParseLax("go.mod", out, nil)
+	if err != nil {
+		t.Fatalf("round-trip ParseLax: %v\n%s", err, out)
+	}
+	out2, err := f2.Format()
+	if err != nil {
+		t.Fatalf("round-trip Format: %v", err)
+	}
+	if string(out) != string(out2) {
+		t.Errorf("Format output not stable across round trip:\nfirst:\n%s\nsecond:\n%s", out, out2)
+	}
+}
+
+func TestParseRetractRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{
+			name: "single version",
+			data: "module m\n\ngo 1.14\n\nretract v1.0.0 // published accidentally\n",
+		},
+		{
+			name: "version range",
+			data: "module m\n\ngo 1.14\n\nretract [v1.0.0, v1.2.0] // broken API\n",
+		},
+		{
+			name: "block",
+			data: "module m\n\ngo 1.14\n\nretract (\n\tv1.0.0 // published accidentally\n\t[v1.1.0, v1.2.0] // broken API\n)\n",
+		},
+	}
+	for _, tt := range tests {
+		f, err := Parse(tt.name, []byte(tt.data), nil)
+		if err != nil {
+			t.Fatalf("%s: Parse: %v", tt.name, err)
+		}
+		if len(f.Retract) == 0 {
+			t.Fatalf("%s: len(f.Retract) = 0, want at least 1", tt.name)
+		}
+
+		out, err := f.Format()
+		if err != nil {
+			t.Fatalf("%s: Format: %v", tt.name, err)
+		}
+		f2, err := Parse(tt.name, out, nil)
+		if err != nil {
+			t.Fatalf("%s: round-trip Parse: %v\n%s", tt.name, err, out)
+		}
+		if len(f2.Retract) != len(f.Retract) {
+			t.Fatalf("%s: round trip changed retract count: got %d, want %d", tt.name, len(f2.Retract), len(f.Retract))
+		}
+		for i, r := range f.Retract {
+			r2 := f2.Retract[i]
+			if r2.VersionInterval != r.VersionInterval {
+				t.Errorf("%s: retract[%d] = %+v, want %+v", tt.name, i, r2.VersionInterval, r.VersionInterval)
+			}
+			if r2.Rationale != r.Rationale {
+				t.Errorf("%s: retract[%d] rationale = %q, want %q", tt.name, i, r2.Rationale, r.Rationale)
+			}
+		}
+	}
+}
+
+func TestParseReplaceWildcard(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantNew string
+	}{
+		{
+			name:    "module replacement",
+			data:    "module m\n\nrequire example.com/foo v1.0.0\n\nreplace example.com/foo => example.com/bar v1.2.3\n",
+			wantNew: "example.com/bar",
+		},
+		{
+			name:    "directory replacement",
+			data:    "module m\n\nrequire example.com/foo v1.0.0\n\nreplace example.com/foo => ./local\n",
+			wantNew: "./local",
+		},
+	}
+	for _, tt := range tests {
+		f, err := Parse(tt.name, []byte(tt.data), nil)
+		if err != nil {
+			t.Fatalf("%s: Parse: %v", tt.name, err)
+		}
+		if len(f.Replace) != 1 {
+			t.Fatalf("%s: len(f.Replace) = %d, want 1", tt.name, len(f.Replace))
+		}
+		r := f.Replace[0]
+		if r.Old.Version != "" {
+			t.Errorf("%s: r.Old.Version = %q, want empty (wildcard)", tt.name, r.Old.Version)
+		}
+		if r.New.Path != tt.wantNew {
+			t.Errorf("%s: r.New.Path = %q, want %q", tt.name, r.New.Path, tt.wantNew)
+		}
+
+		out, err := f.Format()
+		if err != nil {
+			t.Fatalf("%s: Format: %v", tt.name, err)
+		}
+		f2, err := Parse(tt.name, out, nil)
+		if err != nil {
+			t.Fatalf("%s: round-trip Parse: %v\n%s", tt.name, err, out)
+		}
+		if len(f2.Replace) != 1 || f2.Replace[0].Old.Version != "" || f2.Replace[0].New.Path != tt.wantNew {
+			t.Errorf("%s: round trip lost wildcard replace: %+v", tt.name, f2.Replace)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/marwan-at-work/vgop/semver"
+)
+
+// An InvalidMajorVersionError describes a module version whose major
+// version does not match the major version suffix of its module path.
+type InvalidMajorVersionError struct {
+	Version   string
+	PathMajor string
+}
+
+func (e *InvalidMajorVersionError) Error() string {
+	return fmt.Sprintf("module version %s does not match major version suffix %q", e.Version, e.PathMajor)
+}
+
+// CheckPathMajor reports whether version is consistent with pathMajor, the
+// major version suffix of a module path (as returned by a path major
+// version helper such as modfile.modulePathMajor). pathMajor should be
+// "v1" for paths without an explicit major suffix; for gopkg.in paths it
+// is their ".vN" suffix, resolved to the "vN" form by the caller.
+//
+// A version tagged "+incompatible" is exempt, since such versions predate
+// modules and were published without ever having a matching major suffix.
+func CheckPathMajor(version, pathMajor string) error {
+	if strings.HasSuffix(version, "+incompatible") {
+		return nil
+	}
+	if vm := semver.Major(version); vm != pathMajor && (pathMajor != "v1" || vm != "v0") {
+		return &InvalidMajorVersionError{Version: version, PathMajor: pathMajor}
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"strings"
+
+	"github.com/marwan-at-work/vgop/semver"
+)
+
+// CanonicalVersion returns the canonical form of version. Unlike
+// semver.Canonical, it preserves a trailing "+incompatible" build tag,
+// which semver.Canonical otherwise discards along with all other build
+// metadata. Callers that need to keep recognizing +incompatible versions
+// after canonicalizing (such as module.CheckPathMajor) should use this
+// instead of calling semver.Canonical directly.
+func CanonicalVersion(v string) string {
+	cv := semver.Canonical(v)
+	if strings.HasSuffix(v, "+incompatible") {
+		cv += "+incompatible"
+	}
+	return cv
+}